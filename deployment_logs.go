@@ -0,0 +1,303 @@
+package tugboat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// LogSink stores and retrieves the output produced while performing a
+// Deployment.
+type LogSink interface {
+	// Writer returns an io.WriteCloser that appends to the log for
+	// deploymentID. Closing it marks that chunk of output as complete;
+	// it does not mean no more chunks can be written.
+	Writer(deploymentID string) (io.WriteCloser, error)
+
+	// Reader returns an io.ReadCloser of the log for deploymentID. If
+	// follow is true, Read blocks for new output instead of returning
+	// io.EOF once the currently written output has been consumed.
+	Reader(deploymentID string, follow bool) (io.ReadCloser, error)
+}
+
+// logChunk is a single append-only chunk of deployment output.
+type logChunk struct {
+	ID           string    `db:"id"`
+	DeploymentID string    `db:"deployment_id"`
+	Seq          int       `db:"seq"`
+	Data         []byte    `db:"data"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// deploymentLogsStore is the subset of store methods needed to persist log
+// chunks in Postgres.
+type deploymentLogsStore interface {
+	DeploymentLogsAppend(*logChunk) error
+	DeploymentLogs(deploymentID string, after int) ([]*logChunk, error)
+}
+
+// DeploymentLogsAppend inserts a logChunk into the store.
+func (s *store) DeploymentLogsAppend(c *logChunk) error {
+	return s.db.Insert(c)
+}
+
+// DeploymentLogs returns the logChunks for deploymentID with a seq greater
+// than after, ordered by seq.
+func (s *store) DeploymentLogs(deploymentID string, after int) ([]*logChunk, error) {
+	var chunks []*logChunk
+	_, err := s.db.Select(&chunks, `select * from deployment_logs where deployment_id = $1 and seq > $2 order by seq asc`, deploymentID, after)
+	return chunks, err
+}
+
+// postgresLogSink is a LogSink implementation backed by the deployment_logs
+// table. It's meant to be used while a deployment is in progress; Archive
+// should be used to move completed logs to cold storage.
+type postgresLogSink struct {
+	store deploymentLogsStore
+	find  findDeploymentFunc
+}
+
+// newPostgresLogSink returns a new postgresLogSink that persists chunks
+// through store. find is used by a following Reader to notice that the
+// deployment it's tailing has completed.
+func newPostgresLogSink(store deploymentLogsStore, find findDeploymentFunc) *postgresLogSink {
+	return &postgresLogSink{store: store, find: find}
+}
+
+// Writer implements the LogSink interface.
+func (s *postgresLogSink) Writer(deploymentID string) (io.WriteCloser, error) {
+	chunks, err := s.store.DeploymentLogs(deploymentID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Continue after any chunks already written for this deployment.
+	seq := 0
+	if n := len(chunks); n > 0 {
+		seq = chunks[n-1].Seq
+	}
+
+	return &postgresLogWriter{store: s.store, deploymentID: deploymentID, seq: seq}, nil
+}
+
+// Reader implements the LogSink interface.
+func (s *postgresLogSink) Reader(deploymentID string, follow bool) (io.ReadCloser, error) {
+	return &postgresLogReader{store: s.store, find: s.find, deploymentID: deploymentID, follow: follow}, nil
+}
+
+// postgresLogWriter appends each Write call as a new sequenced logChunk.
+type postgresLogWriter struct {
+	store        deploymentLogsStore
+	deploymentID string
+	seq          int
+}
+
+func (w *postgresLogWriter) Write(p []byte) (int, error) {
+	w.seq++
+	chunk := &logChunk{
+		DeploymentID: w.deploymentID,
+		Seq:          w.seq,
+		Data:         append([]byte(nil), p...),
+	}
+	if err := w.store.DeploymentLogsAppend(chunk); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *postgresLogWriter) Close() error {
+	return nil
+}
+
+// pollInterval is how often postgresLogReader checks for new chunks when
+// following a log that hasn't been completed yet.
+const pollInterval = 500 * time.Millisecond
+
+// postgresLogReader streams logChunks for a deployment, optionally blocking
+// for new chunks when follow is true.
+type postgresLogReader struct {
+	store        deploymentLogsStore
+	find         findDeploymentFunc
+	deploymentID string
+	follow       bool
+
+	buf     []byte
+	lastSeq int
+}
+
+func (r *postgresLogReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		chunks, err := r.store.DeploymentLogs(r.deploymentID, r.lastSeq)
+		if err != nil {
+			return 0, err
+		}
+
+		if len(chunks) == 0 {
+			if !r.follow || r.completed() {
+				return 0, io.EOF
+			}
+			time.Sleep(pollInterval)
+			continue
+		}
+
+		for _, c := range chunks {
+			r.buf = append(r.buf, c.Data...)
+			r.lastSeq = c.Seq
+		}
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// completed reports whether the deployment being tailed has finished.
+// follow is decided once, when the reader is created, so a long-lived
+// tail re-checks this on every empty poll instead of following forever.
+func (r *postgresLogReader) completed() bool {
+	if r.find == nil {
+		return false
+	}
+
+	d, err := r.find(r.deploymentID)
+	if err != nil {
+		return false
+	}
+
+	return d.Status.IsCompleted()
+}
+
+func (r *postgresLogReader) Close() error {
+	return nil
+}
+
+// archiveStore is the subset of an object storage client (S3, GCS, ...)
+// needed to archive completed deployment logs.
+type archiveStore interface {
+	Put(key string, body io.Reader) error
+	Get(key string) (io.ReadCloser, error)
+}
+
+// archiveLogSink is a LogSink that serves completed deployment logs from
+// cold storage (S3/GCS) once they've been archived there, falling back to
+// the live sink otherwise.
+type archiveLogSink struct {
+	archive archiveStore
+	live    LogSink
+}
+
+// newArchiveLogSink returns a LogSink that checks archive first and falls
+// back to live.
+func newArchiveLogSink(archive archiveStore, live LogSink) *archiveLogSink {
+	return &archiveLogSink{archive: archive, live: live}
+}
+
+// Writer implements the LogSink interface by delegating to the live sink;
+// archival only happens once a deployment has completed, via Archive.
+func (s *archiveLogSink) Writer(deploymentID string) (io.WriteCloser, error) {
+	return s.live.Writer(deploymentID)
+}
+
+// Reader implements the LogSink interface, preferring the archived copy of
+// the log when one exists.
+func (s *archiveLogSink) Reader(deploymentID string, follow bool) (io.ReadCloser, error) {
+	if r, err := s.archive.Get(archiveKey(deploymentID)); err == nil {
+		return r, nil
+	}
+
+	return s.live.Reader(deploymentID, follow)
+}
+
+// Archive copies the full log for deploymentID from the live sink into cold
+// storage. It should be called once a Deployment reaches a completed
+// status.
+func (s *archiveLogSink) Archive(deploymentID string) error {
+	r, err := s.live.Reader(deploymentID, false)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return s.archive.Put(archiveKey(deploymentID), r)
+}
+
+func archiveKey(deploymentID string) string {
+	return fmt.Sprintf("deployments/%s.log", deploymentID)
+}
+
+// logsHandler serves GET /deploys/:id/logs, streaming a Deployment's log as
+// it's written, either as a text/event-stream or over a websocket upgrade.
+type logsHandler struct {
+	sink  LogSink
+	find  findDeploymentFunc
+	idVar func(*http.Request) string
+}
+
+// newLogsHandler returns an http.Handler that tails deployment logs from
+// sink. idVar extracts the deployment id from the request (e.g. a router's
+// path variable).
+func newLogsHandler(sink LogSink, find findDeploymentFunc, idVar func(*http.Request) string) *logsHandler {
+	return &logsHandler{sink: sink, find: find, idVar: idVar}
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+func (h *logsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := h.idVar(r)
+
+	d, err := h.find(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	rc, err := h.sink.Reader(d.ID, !d.Status.IsCompleted())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rc.Close()
+
+	if websocket.IsWebSocketUpgrade(r) {
+		h.serveWebSocket(w, r, rc)
+		return
+	}
+
+	h.serveEventStream(w, rc)
+}
+
+func (h *logsHandler) serveEventStream(w http.ResponseWriter, r io.Reader) {
+	w.Header().Set("Content-Type", "text/event-stream")
+
+	flusher, _ := w.(http.Flusher)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fmt.Fprintf(w, "data: %s\n\n", scanner.Text())
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+func (h *logsHandler) serveWebSocket(w http.ResponseWriter, r *http.Request, rc io.Reader) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(rc)
+	for scanner.Scan() {
+		if err := conn.WriteMessage(websocket.TextMessage, scanner.Bytes()); err != nil {
+			return
+		}
+	}
+}
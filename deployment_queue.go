@@ -0,0 +1,360 @@
+package tugboat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	gorp "gopkg.in/gorp.v1"
+)
+
+// JobStatus represents the status of a queued deployment job.
+type JobStatus string
+
+// The various states that a deployment job can be in.
+const (
+	JobQueued    JobStatus = "queued"
+	JobDeploying JobStatus = "deploying"
+	JobDeployed  JobStatus = "deployed"
+	JobFailed    JobStatus = "failed"
+)
+
+// DeploymentJob represents a Deployment that's waiting to be (or is being)
+// performed by a worker.
+type DeploymentJob struct {
+	ID           string     `db:"id"`
+	DeploymentID string     `db:"deployment_id"`
+	Repo         string     `db:"repo"`
+	Environment  string     `db:"environment"`
+	Status       JobStatus  `db:"status"`
+	CreatedAt    time.Time  `db:"created_at"`
+	StartedAt    *time.Time `db:"started_at"`
+}
+
+// PreInsert implements a pre insert hook for the db interface.
+func (j *DeploymentJob) PreInsert(s gorp.SqlExecutor) error {
+	j.CreatedAt = time.Now()
+	j.Status = JobQueued
+	return nil
+}
+
+// jobKey returns the key used to serialize jobs for the same repo and
+// environment.
+func (j *DeploymentJob) jobKey() string {
+	return jobKey(j.Repo, j.Environment)
+}
+
+// jobKey returns the serialization key for a given repo and environment.
+func jobKey(repo, environment string) string {
+	return fmt.Sprintf("%s:%s", repo, environment)
+}
+
+// deploymentJobsStore is the subset of store methods that deploymentQueue
+// needs to persist jobs.
+type deploymentJobsStore interface {
+	DeploymentJobsCreate(*DeploymentJob) error
+	DeploymentJobsUpdate(*DeploymentJob) error
+	DeploymentJobsDelete(*DeploymentJob) error
+	DeploymentJobs(q DeploymentJobsQuery) ([]*DeploymentJob, error)
+}
+
+// DeploymentJobsQuery is a query object for querying DeploymentJobs.
+type DeploymentJobsQuery struct {
+	Status JobStatus
+}
+
+// DeploymentJobsCreate inserts a DeploymentJob into the store.
+func (s *store) DeploymentJobsCreate(j *DeploymentJob) error {
+	return s.db.Insert(j)
+}
+
+// DeploymentJobsUpdate updates a DeploymentJob in the store.
+func (s *store) DeploymentJobsUpdate(j *DeploymentJob) error {
+	_, err := s.db.Update(j)
+	return err
+}
+
+// DeploymentJobsDelete removes a DeploymentJob from the store.
+func (s *store) DeploymentJobsDelete(j *DeploymentJob) error {
+	_, err := s.db.Delete(j)
+	return err
+}
+
+// DeploymentJobs returns DeploymentJobs matching q.
+func (s *store) DeploymentJobs(q DeploymentJobsQuery) ([]*DeploymentJob, error) {
+	var j []*DeploymentJob
+
+	if q.Status == "" {
+		_, err := s.db.Select(&j, `select * from deployment_jobs order by created_at asc`)
+		return j, err
+	}
+
+	_, err := s.db.Select(&j, `select * from deployment_jobs where status = $1 order by created_at asc`, string(q.Status))
+	return j, err
+}
+
+// deployFunc performs the actual deployment work for a queued job.
+type deployFunc func(*Deployment) error
+
+// findDeploymentFunc looks up a Deployment by its id.
+type findDeploymentFunc func(id string) (*Deployment, error)
+
+// deploymentQueue is a deploymentsService decorator that persists
+// deployments into a queue and runs them with a pool of workers, guaranteeing
+// that at most one deployment is in flight for a given (Repo, Environment) at
+// a time.
+type deploymentQueue struct {
+	deploymentsService
+
+	jobs    deploymentJobsStore
+	find    findDeploymentFunc
+	deploy  deployFunc
+	workers int
+
+	mu       sync.Mutex
+	running  map[string]bool
+	pending  map[string][]*DeploymentJob
+	canceled map[string]bool
+	jobCh    chan *DeploymentJob
+}
+
+// newDeploymentQueue returns a new deploymentQueue that enqueues deployments
+// created through service, and dispatches them to deploy once a worker for
+// their (Repo, Environment) key is free.
+func newDeploymentQueue(service deploymentsService, jobs deploymentJobsStore, find findDeploymentFunc, deploy deployFunc, workers int) *deploymentQueue {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	return &deploymentQueue{
+		deploymentsService: service,
+		jobs:               jobs,
+		find:               find,
+		deploy:             deploy,
+		workers:            workers,
+		running:            make(map[string]bool),
+		pending:            make(map[string][]*DeploymentJob),
+		canceled:           make(map[string]bool),
+		jobCh:              make(chan *DeploymentJob, 1024),
+	}
+}
+
+// Start launches the worker pool, then re-dispatches any jobs that were left
+// queued or in flight the last time the process stopped. It returns once
+// those jobs have been handed off; the workers keep running until stop is
+// closed.
+func (q *deploymentQueue) Start(stop <-chan struct{}) {
+	for i := 0; i < q.workers; i++ {
+		go q.worker(stop)
+	}
+
+	q.resume()
+}
+
+// resume reloads DeploymentJobs that were queued, or that were still
+// deploying when the process last stopped, and hands them back to the
+// worker pool so the deployment_jobs table isn't a dead end across
+// restarts. It's called after the workers are already running so that
+// dispatch, which can block on jobCh, always has a reader.
+func (q *deploymentQueue) resume() {
+	for _, status := range []JobStatus{JobQueued, JobDeploying} {
+		jobs, err := q.jobs.DeploymentJobs(DeploymentJobsQuery{Status: status})
+		if err != nil {
+			continue
+		}
+
+		for _, job := range jobs {
+			q.dispatch(job)
+		}
+	}
+}
+
+// Enqueue persists d as a queued DeploymentJob and hands it to a worker as
+// soon as no other deployment is running for the same (Repo, Environment)
+// key.
+func (q *deploymentQueue) Enqueue(d *Deployment) error {
+	if err := q.deploymentsService.DeploymentsCreate(d); err != nil {
+		return err
+	}
+
+	job := &DeploymentJob{
+		DeploymentID: d.ID,
+		Repo:         d.Repo,
+		Environment:  d.Environment,
+	}
+
+	if err := q.jobs.DeploymentJobsCreate(job); err != nil {
+		return err
+	}
+
+	q.dispatch(job)
+
+	return nil
+}
+
+// Cancel marks job as canceled so that it will never be dispatched to a
+// worker, even if it's already sitting in the in-memory queue, and deletes
+// the job from the store. It doesn't stop a job that's already deploying:
+// that deployment still runs, though its job record is deleted.
+func (q *deploymentQueue) Cancel(job *DeploymentJob) error {
+	q.mu.Lock()
+	q.canceled[job.ID] = true
+	q.mu.Unlock()
+
+	return q.jobs.DeploymentJobsDelete(job)
+}
+
+// dispatch hands job to a worker via jobCh if no other deployment is
+// running for its (Repo, Environment) key. Otherwise, it's appended to the
+// pending queue for that key and picked up once the running one finishes,
+// so a busy key never ties up a worker and jobs for other keys keep
+// flowing.
+func (q *deploymentQueue) dispatch(job *DeploymentJob) {
+	key := job.jobKey()
+
+	q.mu.Lock()
+	if q.running[key] {
+		q.pending[key] = append(q.pending[key], job)
+		q.mu.Unlock()
+		return
+	}
+	q.running[key] = true
+	q.mu.Unlock()
+
+	q.jobCh <- job
+}
+
+// finish marks key as no longer running and, if another job is pending for
+// it, returns that job so the calling worker can continue processing it
+// directly instead of returning it to jobCh.
+func (q *deploymentQueue) finish(key string) *DeploymentJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	next := q.pending[key]
+	if len(next) == 0 {
+		delete(q.running, key)
+		return nil
+	}
+
+	job := next[0]
+	if len(next) == 1 {
+		delete(q.pending, key)
+	} else {
+		q.pending[key] = next[1:]
+	}
+
+	return job
+}
+
+func (q *deploymentQueue) worker(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case job := <-q.jobCh:
+			q.run(job)
+		}
+	}
+}
+
+// run performs job, then keeps processing whatever job is queued next for
+// the same (Repo, Environment) key, so the worker stays dedicated to
+// draining that key's backlog in order rather than parking or bouncing
+// through jobCh.
+func (q *deploymentQueue) run(job *DeploymentJob) {
+	for job != nil {
+		job = q.runOne(job)
+	}
+}
+
+// runOne performs a single job, which must already be marked as running for
+// its key, and returns the next pending job for that key, if any.
+func (q *deploymentQueue) runOne(job *DeploymentJob) (next *DeploymentJob) {
+	key := job.jobKey()
+	defer func() { next = q.finish(key) }()
+
+	q.mu.Lock()
+	canceled := q.canceled[job.ID]
+	delete(q.canceled, job.ID)
+	q.mu.Unlock()
+	if canceled {
+		return
+	}
+
+	job.Status = JobDeploying
+	t := time.Now()
+	job.StartedAt = &t
+	if err := q.jobs.DeploymentJobsUpdate(job); err != nil {
+		return
+	}
+
+	d, err := q.find(job.DeploymentID)
+	if err != nil {
+		job.Status = JobFailed
+		q.jobs.DeploymentJobsUpdate(job)
+		return
+	}
+
+	d.Started(d.Provider)
+	q.deploymentsService.DeploymentsUpdate(d)
+
+	status := JobDeployed
+	if err := q.deploy(d); err != nil {
+		status = JobFailed
+	}
+
+	job.Status = status
+	q.jobs.DeploymentJobsUpdate(job)
+	return
+}
+
+// ServeHTTP implements a handler that, for GET requests, lists queued and
+// in-flight jobs, and for DELETE requests, cancels a queued job.
+func (q *deploymentQueue) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		q.listJobs(w, r)
+	case "DELETE":
+		q.cancelJob(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (q *deploymentQueue) listJobs(w http.ResponseWriter, r *http.Request) {
+	queued, err := q.jobs.DeploymentJobs(DeploymentJobsQuery{Status: JobQueued})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	deploying, err := q.jobs.DeploymentJobs(DeploymentJobsQuery{Status: JobDeploying})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Queued    []*DeploymentJob `json:"queued"`
+		Deploying []*DeploymentJob `json:"deploying"`
+	}{queued, deploying})
+}
+
+func (q *deploymentQueue) cancelJob(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := q.Cancel(&DeploymentJob{ID: id}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,305 @@
+package tugboat
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeJobsStore is an in-memory deploymentJobsStore for tests.
+type fakeJobsStore struct {
+	mu   sync.Mutex
+	jobs map[string]*DeploymentJob
+	seq  int
+}
+
+func newFakeJobsStore() *fakeJobsStore {
+	return &fakeJobsStore{jobs: make(map[string]*DeploymentJob)}
+}
+
+func (s *fakeJobsStore) DeploymentJobsCreate(j *DeploymentJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	j.ID = fmt.Sprintf("job-%d", s.seq)
+	j.Status = JobQueued
+	s.jobs[j.ID] = j
+	return nil
+}
+
+func (s *fakeJobsStore) DeploymentJobsUpdate(j *DeploymentJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[j.ID] = j
+	return nil
+}
+
+func (s *fakeJobsStore) DeploymentJobsDelete(j *DeploymentJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.jobs, j.ID)
+	return nil
+}
+
+func (s *fakeJobsStore) DeploymentJobs(q DeploymentJobsQuery) ([]*DeploymentJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []*DeploymentJob
+	for _, j := range s.jobs {
+		if q.Status == "" || j.Status == q.Status {
+			out = append(out, j)
+		}
+	}
+	return out, nil
+}
+
+// fakeDeploymentsService is an in-memory deploymentsService for tests.
+type fakeDeploymentsService struct {
+	mu          sync.Mutex
+	deployments map[string]*Deployment
+	seq         int
+}
+
+func newFakeDeploymentsService() *fakeDeploymentsService {
+	return &fakeDeploymentsService{deployments: make(map[string]*Deployment)}
+}
+
+func (s *fakeDeploymentsService) DeploymentsCreate(d *Deployment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	d.ID = fmt.Sprintf("deployment-%d", s.seq)
+	s.deployments[d.ID] = d
+	return nil
+}
+
+func (s *fakeDeploymentsService) DeploymentsUpdate(d *Deployment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deployments[d.ID] = d
+	return nil
+}
+
+func (s *fakeDeploymentsService) find(id string) (*Deployment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.deployments[id]
+	if !ok {
+		return nil, fmt.Errorf("deployment %q not found", id)
+	}
+	return d, nil
+}
+
+// waitUntil polls cond every few milliseconds until it returns true, failing
+// the test if it doesn't happen within timeout.
+func waitUntil(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("timed out waiting for condition")
+}
+
+// TestDeploymentQueue_ParallelAcrossKeys verifies that a backlog of jobs
+// queued for one (Repo, Environment) key doesn't tie up workers that could
+// otherwise be deploying a different key.
+func TestDeploymentQueue_ParallelAcrossKeys(t *testing.T) {
+	deployments := newFakeDeploymentsService()
+	jobs := newFakeJobsStore()
+
+	release := make(chan struct{})
+	blocked := make(chan string, 1)
+
+	deploy := func(d *Deployment) error {
+		if d.Environment == "busy" {
+			select {
+			case blocked <- d.ID:
+			default:
+			}
+			<-release
+		}
+		d.Succeeded()
+		return deployments.DeploymentsUpdate(d)
+	}
+
+	q := newDeploymentQueue(deployments, jobs, deployments.find, deploy, 2)
+	stop := make(chan struct{})
+	defer close(stop)
+	q.Start(stop)
+
+	// Queue up several jobs for the "busy" key, then one for "free". If a
+	// blocked worker were still occupying a pool slot, the "free" job
+	// would never run while "busy" jobs are still backed up.
+	for i := 0; i < 3; i++ {
+		d := &Deployment{Repo: "acme/www", Environment: "busy"}
+		if err := q.Enqueue(d); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+
+	waitUntil(t, time.Second, func() bool { return len(blocked) == 1 })
+
+	free := &Deployment{Repo: "acme/www", Environment: "free"}
+	if err := q.Enqueue(free); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	waitUntil(t, time.Second, func() bool {
+		d, err := deployments.find(free.ID)
+		return err == nil && d.Status == StatusSucceeded
+	})
+
+	close(release)
+}
+
+// TestDeploymentQueue_SerializesPerKey verifies that jobs for the same
+// (Repo, Environment) key never run concurrently.
+func TestDeploymentQueue_SerializesPerKey(t *testing.T) {
+	deployments := newFakeDeploymentsService()
+	jobs := newFakeJobsStore()
+
+	var mu sync.Mutex
+	running := 0
+	maxRunning := 0
+
+	deploy := func(d *Deployment) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		d.Succeeded()
+		return deployments.DeploymentsUpdate(d)
+	}
+
+	q := newDeploymentQueue(deployments, jobs, deployments.find, deploy, 4)
+	stop := make(chan struct{})
+	defer close(stop)
+	q.Start(stop)
+
+	var last *Deployment
+	for i := 0; i < 5; i++ {
+		d := &Deployment{Repo: "acme/www", Environment: "production"}
+		if err := q.Enqueue(d); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+		last = d
+	}
+
+	waitUntil(t, time.Second, func() bool {
+		d, err := deployments.find(last.ID)
+		return err == nil && d.Status == StatusSucceeded
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if maxRunning > 1 {
+		t.Errorf("maxRunning = %d, want 1", maxRunning)
+	}
+}
+
+// TestDeploymentQueue_Cancel verifies that a canceled job is skipped instead
+// of being deployed.
+func TestDeploymentQueue_Cancel(t *testing.T) {
+	deployments := newFakeDeploymentsService()
+	jobs := newFakeJobsStore()
+
+	deployed := false
+	deploy := func(d *Deployment) error {
+		deployed = true
+		return nil
+	}
+
+	q := newDeploymentQueue(deployments, jobs, deployments.find, deploy, 1)
+
+	d := &Deployment{Repo: "acme/www", Environment: "production"}
+	if err := deployments.DeploymentsCreate(d); err != nil {
+		t.Fatalf("DeploymentsCreate: %v", err)
+	}
+
+	job := &DeploymentJob{DeploymentID: d.ID, Repo: d.Repo, Environment: d.Environment}
+	if err := jobs.DeploymentJobsCreate(job); err != nil {
+		t.Fatalf("DeploymentJobsCreate: %v", err)
+	}
+
+	if err := q.Cancel(job); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	q.Start(stop)
+	q.dispatch(job)
+
+	time.Sleep(20 * time.Millisecond)
+	if deployed {
+		t.Error("canceled job was deployed")
+	}
+}
+
+// TestDeploymentQueue_Resume verifies that Start re-dispatches jobs left
+// over from a previous run, even when there are more of them than jobCh's
+// buffer can hold without a reader.
+func TestDeploymentQueue_Resume(t *testing.T) {
+	deployments := newFakeDeploymentsService()
+	jobs := newFakeJobsStore()
+
+	const n = 1500
+	for i := 0; i < n; i++ {
+		d := &Deployment{Repo: "acme/www", Environment: fmt.Sprintf("env-%d", i)}
+		if err := deployments.DeploymentsCreate(d); err != nil {
+			t.Fatalf("DeploymentsCreate: %v", err)
+		}
+
+		job := &DeploymentJob{DeploymentID: d.ID, Repo: d.Repo, Environment: d.Environment}
+		if err := jobs.DeploymentJobsCreate(job); err != nil {
+			t.Fatalf("DeploymentJobsCreate: %v", err)
+		}
+	}
+
+	var done sync.WaitGroup
+	done.Add(n)
+	deploy := func(d *Deployment) error {
+		done.Done()
+		return nil
+	}
+
+	q := newDeploymentQueue(deployments, jobs, deployments.find, deploy, 8)
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	finished := make(chan struct{})
+	go func() {
+		done.Wait()
+		close(finished)
+	}()
+
+	go q.Start(stop)
+
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for resumed jobs to run")
+	}
+}
@@ -40,6 +40,43 @@ type DeployOpts struct {
 	// this would be the platform that is being deployed to (e.g.
 	// heroku/empire).
 	Provider string
+
+	// The type of deployment being performed (e.g. `deploy`,
+	// `deploy:migrations`).
+	Task string
+
+	// Opaque JSON payload provided when the deployment was triggered.
+	Payload []byte
+
+	// The login of the user that triggered this deployment.
+	Creator string
+
+	// The id of the user that triggered this deployment.
+	CreatorID int64
+
+	// True if this deployment is to a transient, disposable environment
+	// (e.g. a PR preview environment).
+	TransientEnvironment bool
+
+	// True if this deployment is to a production environment.
+	ProductionEnvironment bool
+
+	// A list of commit statuses that must be "success" on Sha before the
+	// deployment is allowed to proceed.
+	RequiredContexts []string
+
+	// Whether or not to automatically merge the default branch into
+	// Ref before deploying, mirroring GitHub's auto_merge deployment
+	// option. Left nil to use GitHub's default.
+	AutoMerge *bool
+
+	// A url to view the environment that this deployment is deployed
+	// to, once available. Included in deployment statuses posted to
+	// GitHub as environment_url.
+	EnvironmentURL string
+
+	// The GitHub API url for creating statuses on this deployment.
+	StatusesURL string
 }
 
 // NewDeployOptsFromWebhook instantiates a new DeployOpts instance based on the
@@ -52,12 +89,19 @@ func NewDeployOptsFromReader(r io.Reader) (DeployOpts, error) {
 	}
 
 	return DeployOpts{
-		ID:          f.Deployment.ID,
-		Sha:         f.Deployment.Sha,
-		Ref:         f.Deployment.Ref,
-		Environment: f.Deployment.Environment,
-		Description: f.Deployment.Description,
-		Repo:        f.Repository.FullName,
+		ID:                    f.Deployment.ID,
+		Sha:                   f.Deployment.Sha,
+		Ref:                   f.Deployment.Ref,
+		Environment:           f.Deployment.Environment,
+		Description:           f.Deployment.Description,
+		Repo:                  f.Repository.FullName,
+		Task:                  f.Deployment.Task,
+		Payload:               f.Deployment.Payload,
+		Creator:               f.Deployment.Creator.Login,
+		CreatorID:             f.Deployment.Creator.ID,
+		TransientEnvironment:  f.Deployment.TransientEnvironment,
+		ProductionEnvironment: f.Deployment.ProductionEnvironment,
+		StatusesURL:           f.Deployment.StatusesURL,
 	}, nil
 }
 
@@ -181,6 +225,34 @@ type Deployment struct {
 	// If the deployment failed, contains an error message.
 	Error string `db:"error"`
 
+	// The type of deployment being performed (e.g. `deploy`,
+	// `deploy:migrations`).
+	Task string `db:"task"`
+
+	// Opaque JSON payload provided when the deployment was triggered.
+	Payload []byte `db:"payload"`
+
+	// The login of the user that triggered this deployment.
+	Creator string `db:"creator"`
+
+	// The id of the user that triggered this deployment.
+	CreatorID int64 `db:"creator_id"`
+
+	// True if this deployment is to a transient, disposable environment
+	// (e.g. a PR preview environment).
+	TransientEnvironment bool `db:"transient_environment"`
+
+	// True if this deployment is to a production environment.
+	ProductionEnvironment bool `db:"production_environment"`
+
+	// The GitHub API url for creating statuses on this deployment.
+	StatusesURL string `db:"statuses_url"`
+
+	// A url to view the environment that this deployment is deployed
+	// to. Included in deployment statuses posted to GitHub as
+	// environment_url.
+	EnvironmentURL string `db:"environment_url"`
+
 	// The time that this deployment was created.
 	CreatedAt time.Time `db:"created_at"`
 
@@ -196,12 +268,21 @@ type Deployment struct {
 // newDeployment returns a new Deployment instance based on the options.
 func newDeployment(opts DeployOpts) *Deployment {
 	return &Deployment{
-		GitHubID:    opts.ID,
-		Sha:         opts.Sha,
-		Ref:         opts.Ref,
-		Environment: opts.Environment,
-		Description: opts.Description,
-		Repo:        opts.Repo,
+		GitHubID:              opts.ID,
+		Sha:                   opts.Sha,
+		Ref:                   opts.Ref,
+		Environment:           opts.Environment,
+		Description:           opts.Description,
+		Repo:                  opts.Repo,
+		Provider:              opts.Provider,
+		Task:                  opts.Task,
+		Payload:               opts.Payload,
+		Creator:               opts.Creator,
+		CreatorID:             opts.CreatorID,
+		TransientEnvironment:  opts.TransientEnvironment,
+		ProductionEnvironment: opts.ProductionEnvironment,
+		EnvironmentURL:        opts.EnvironmentURL,
+		StatusesURL:           opts.StatusesURL,
 	}
 }
 
@@ -251,11 +332,6 @@ func (d *Deployment) changeStatus(status DeploymentStatus) {
 	d.prevStatus, d.Status = d.Status, status
 }
 
-// DeploymentsQuery is a query object for querying Deployments.
-type DeploymentsQuery struct {
-	Limit int
-}
-
 // DeploymentsCreate inserts a Deployment into the store.
 func (s *store) DeploymentsCreate(d *Deployment) error {
 	return s.db.Insert(d)
@@ -267,19 +343,6 @@ func (s *store) DeploymentsUpdate(d *Deployment) error {
 	return err
 }
 
-// Deployments returns the most recent Deployments.
-func (s *store) Deployments(q DeploymentsQuery) ([]*Deployment, error) {
-	var d []*Deployment
-
-	limit := q.Limit
-	if limit == 0 {
-		limit = DefaultDeploymentsLimit
-	}
-
-	_, err := s.db.Select(&d, fmt.Sprintf(`select * from deployments order by github_id desc limit %d`, limit))
-	return d, err
-}
-
 // DeploymentsFind finds a Deployment by id.
 func (s *store) DeploymentsFind(id string) (*Deployment, error) {
 	var d Deployment
@@ -0,0 +1,176 @@
+package tugboat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/CodeLingoBot/tugboat/github"
+)
+
+// descriptionTemplate is used as the deployment status description when no
+// error is present.
+const descriptionTemplate = "Deployment to %s"
+
+// Deployments is the main entry point for creating deployments. It wraps a
+// deploymentsService and a GitHub client so that a Deployment can be created
+// even when no inbound `deployment` webhook has fired yet.
+type Deployments struct {
+	service deploymentsService
+	github  *github.Client
+}
+
+// NewDeployments returns a new Deployments that creates Deployments through
+// service, auto-creating the upstream GitHub deployment via client when
+// necessary.
+func NewDeployments(service deploymentsService, client *github.Client) *Deployments {
+	return &Deployments{
+		service: service,
+		github:  client,
+	}
+}
+
+// ErrRequiredContextsFailing is returned by Deployments.Create when one or
+// more of DeployOpts.RequiredContexts isn't passing on DeployOpts.Sha.
+var ErrRequiredContextsFailing = fmt.Errorf("tugboat: required contexts are not all success")
+
+// Create creates a new Deployment from opts. If opts doesn't have a
+// GitHub deployment id (i.e. it wasn't triggered by an inbound webhook), a
+// deployment is first created on GitHub so that tugboat remains the source
+// of truth for the deployment's status.
+func (d *Deployments) Create(ctx context.Context, opts DeployOpts) (*Deployment, error) {
+	owner, repo, err := splitRepo(opts.Repo)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.RequiredContexts) > 0 {
+		ok, err := d.requiredContextsPassing(ctx, owner, repo, opts.Sha, opts.RequiredContexts)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrRequiredContextsFailing
+		}
+	}
+
+	if opts.Provider == "" {
+		if p, ok := SelectProvider(opts); ok {
+			opts.Provider = p.Name()
+		}
+	}
+
+	if opts.ID == 0 {
+		resp, err := d.github.CreateDeployment(ctx, owner, repo, &github.DeploymentRequest{
+			Ref:                   opts.Ref,
+			Task:                  opts.Task,
+			AutoMerge:             opts.AutoMerge,
+			RequiredContexts:      opts.RequiredContexts,
+			Payload:               opts.Payload,
+			Environment:           opts.Environment,
+			Description:           opts.Description,
+			TransientEnvironment:  opts.TransientEnvironment,
+			ProductionEnvironment: opts.ProductionEnvironment,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		opts.ID = resp.ID
+		opts.Sha = resp.Sha
+		opts.StatusesURL = resp.StatusesURL
+		opts.Creator = resp.Creator.Login
+		opts.CreatorID = resp.Creator.ID
+	}
+
+	deployment := newDeployment(opts)
+	if err := d.service.DeploymentsCreate(deployment); err != nil {
+		return nil, err
+	}
+
+	return deployment, nil
+}
+
+// requiredContextsPassing returns true if every context in required is
+// "success" in the combined status of sha.
+func (d *Deployments) requiredContextsPassing(ctx context.Context, owner, repo, sha string, required []string) (bool, error) {
+	combined, err := d.github.GetCombinedStatus(ctx, owner, repo, sha)
+	if err != nil {
+		return false, err
+	}
+
+	success := make(map[string]bool)
+	for _, s := range combined.Statuses {
+		success[s.Context] = s.State == "success"
+	}
+
+	for _, c := range required {
+		if !success[c] {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// githubStatusUpdater is a statusUpdater implementation that updates the
+// status of the associated deployment on GitHub.
+type githubStatusUpdater struct {
+	github *github.Client
+}
+
+// newGitHubStatusUpdater returns a new githubStatusUpdater that updates
+// deployment statuses using client.
+func newGitHubStatusUpdater(client *github.Client) *githubStatusUpdater {
+	return &githubStatusUpdater{github: client}
+}
+
+// UpdateStatus implements the statusUpdater interface by posting a matching
+// deployment status to GitHub.
+func (u *githubStatusUpdater) UpdateStatus(d *Deployment) error {
+	owner, repo, err := splitRepo(d.Repo)
+	if err != nil {
+		return err
+	}
+
+	description := d.Error
+	if description == "" {
+		description = fmt.Sprintf(descriptionTemplate, d.Environment)
+	}
+
+	_, err = u.github.CreateDeploymentStatus(context.Background(), owner, repo, d.GitHubID, &github.DeploymentStatusRequest{
+		State:          githubState(d.Status),
+		TargetURL:      d.URL(),
+		Description:    description,
+		Environment:    d.Environment,
+		EnvironmentURL: d.EnvironmentURL,
+	})
+	return err
+}
+
+// githubState maps a DeploymentStatus to the state values accepted by the
+// GitHub deployment statuses API.
+func githubState(s DeploymentStatus) string {
+	switch s {
+	case StatusStarted:
+		return "pending"
+	case StatusSucceeded:
+		return "success"
+	case StatusFailed:
+		return "failure"
+	case StatusErrored:
+		return "error"
+	default:
+		return "pending"
+	}
+}
+
+// splitRepo splits a "owner/repo" string into its owner and repo parts.
+func splitRepo(fullName string) (owner, repo string, err error) {
+	parts := strings.SplitN(fullName, "/", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("tugboat: invalid repo: %q", fullName)
+	}
+
+	return parts[0], parts[1], nil
+}
@@ -0,0 +1,238 @@
+package tugboat
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeploymentsQuery is a query object for querying Deployments.
+type DeploymentsQuery struct {
+	// Limit is the maximum number of Deployments to return. Defaults to
+	// DefaultDeploymentsLimit.
+	Limit int
+
+	// Repo filters to Deployments for this repo.
+	Repo string
+
+	// Environment filters to Deployments to this environment.
+	Environment string
+
+	// Sha filters to Deployments of this git sha.
+	Sha string
+
+	// Ref filters to Deployments of this git ref.
+	Ref string
+
+	// Status filters to Deployments in this status.
+	Status *DeploymentStatus
+
+	// Provider filters to Deployments performed by this provider.
+	Provider string
+
+	// Since filters to Deployments created at or after this time.
+	Since *time.Time
+
+	// Until filters to Deployments created before this time.
+	Until *time.Time
+
+	// Cursor is an opaque pagination cursor, as returned by calling
+	// Cursor(q.Order) on the last Deployment of a previous page. A
+	// cursor is only valid for the Order it was produced with.
+	Cursor string
+
+	// Order determines the sort order of the results. Should be one of
+	// "created_at" or "github_id", optionally followed by " asc" or
+	// " desc" (e.g. "github_id asc"); the direction defaults to desc.
+	// Defaults to "created_at desc".
+	Order string
+}
+
+// Cursor returns an opaque pagination cursor that can be used as the Cursor
+// field of a DeploymentsQuery to fetch the page of Deployments following d,
+// when querying with the same order. order should be the Order the
+// Deployments were queried with (empty defaults to "created_at desc", same
+// as DeploymentsQuery).
+func (d *Deployment) Cursor(order string) (string, error) {
+	column, _, err := (DeploymentsQuery{Order: order}).orderColumn()
+	if err != nil {
+		return "", err
+	}
+
+	switch column {
+	case "github_id":
+		return encodeCursor(column, strconv.FormatInt(d.GitHubID, 10), d.ID), nil
+	default:
+		return encodeCursor(column, strconv.FormatInt(d.CreatedAt.UnixNano(), 10), d.ID), nil
+	}
+}
+
+// encodeCursor encodes the order column being paginated on, that column's
+// value, and an id into an opaque cursor string.
+func encodeCursor(column, value, id string) string {
+	raw := fmt.Sprintf("%s:%s:%s", column, value, id)
+	return base64.URLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor decodes a cursor produced by encodeCursor.
+func decodeCursor(cursor string) (column, value, id string, err error) {
+	raw, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", "", "", fmt.Errorf("tugboat: invalid cursor: %v", err)
+	}
+
+	parts := strings.SplitN(string(raw), ":", 3)
+	if len(parts) != 3 {
+		return "", "", "", fmt.Errorf("tugboat: invalid cursor: %q", cursor)
+	}
+
+	return parts[0], parts[1], parts[2], nil
+}
+
+// allowedOrderColumns is the set of columns that can be sorted on, to avoid
+// building a query with an unsanitized column name.
+var allowedOrderColumns = map[string]bool{
+	"created_at": true,
+	"github_id":  true,
+}
+
+// Deployments returns Deployments matching q.
+func (s *store) Deployments(q DeploymentsQuery) ([]*Deployment, error) {
+	var d []*Deployment
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultDeploymentsLimit
+	}
+
+	order, err := q.orderBy()
+	if err != nil {
+		return nil, err
+	}
+
+	var where bytes.Buffer
+	var args []interface{}
+
+	add := func(clause string, arg interface{}) {
+		if where.Len() == 0 {
+			where.WriteString("where ")
+		} else {
+			where.WriteString(" and ")
+		}
+		args = append(args, arg)
+		fmt.Fprintf(&where, clause, len(args))
+	}
+
+	if q.Repo != "" {
+		add("repo = $%d", q.Repo)
+	}
+	if q.Environment != "" {
+		add("environment = $%d", q.Environment)
+	}
+	if q.Sha != "" {
+		add("sha = $%d", q.Sha)
+	}
+	if q.Ref != "" {
+		add("ref = $%d", q.Ref)
+	}
+	if q.Status != nil {
+		add("status = $%d", *q.Status)
+	}
+	if q.Provider != "" {
+		add("provider = $%d", q.Provider)
+	}
+	if q.Since != nil {
+		add("created_at >= $%d", *q.Since)
+	}
+	if q.Until != nil {
+		add("created_at < $%d", *q.Until)
+	}
+	if q.Cursor != "" {
+		column, direction, err := q.orderColumn()
+		if err != nil {
+			return nil, err
+		}
+
+		cursorColumn, value, id, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursorColumn != column {
+			return nil, fmt.Errorf("tugboat: cursor is for order %q, not %q", cursorColumn, column)
+		}
+
+		var cursorValue interface{}
+		switch column {
+		case "github_id":
+			cursorValue, err = strconv.ParseInt(value, 10, 64)
+		default:
+			var nanos int64
+			nanos, err = strconv.ParseInt(value, 10, 64)
+			cursorValue = time.Unix(0, nanos)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tugboat: invalid cursor: %v", err)
+		}
+
+		op := "<"
+		if direction == "asc" {
+			op = ">"
+		}
+
+		args = append(args, cursorValue, id)
+		if where.Len() == 0 {
+			where.WriteString("where ")
+		} else {
+			where.WriteString(" and ")
+		}
+		fmt.Fprintf(&where, "(%s, id) %s ($%d, $%d)", column, op, len(args)-1, len(args))
+	}
+
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`select * from deployments %s order by %s limit $%d`, where.String(), order, len(args))
+
+	_, err = s.db.Select(&d, query, args...)
+	return d, err
+}
+
+// orderColumn validates and splits q.Order into its column and direction,
+// defaulting to "created_at desc".
+func (q DeploymentsQuery) orderColumn() (column, direction string, err error) {
+	order := q.Order
+	if order == "" {
+		order = "created_at desc"
+	}
+
+	parts := strings.Fields(order)
+	column = parts[0]
+	direction = "desc"
+	if len(parts) > 1 {
+		direction = strings.ToLower(parts[1])
+	}
+
+	if !allowedOrderColumns[column] {
+		return "", "", fmt.Errorf("tugboat: invalid order column: %q", column)
+	}
+	if direction != "asc" && direction != "desc" {
+		return "", "", fmt.Errorf("tugboat: invalid order direction: %q", direction)
+	}
+
+	return column, direction, nil
+}
+
+// orderBy validates and returns the ORDER BY clause for q. id is appended as
+// a tiebreaker, in the same direction, so rows that share a value for
+// column still come back in a well-defined order matching the keyset
+// cursor's (column, id) comparison.
+func (q DeploymentsQuery) orderBy() (string, error) {
+	column, direction, err := q.orderColumn()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s %s, id %s", column, direction, direction), nil
+}
@@ -0,0 +1,66 @@
+package tugboat
+
+import "testing"
+
+// TestDeploymentCursorRoundTrip verifies that a cursor produced by
+// Deployment.Cursor decodes back to the order column and the Deployment id
+// that produced it, for both supported orders.
+func TestDeploymentCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		order  string
+		column string
+	}{
+		{"", "created_at"},
+		{"created_at desc", "created_at"},
+		{"created_at asc", "created_at"},
+		{"github_id desc", "github_id"},
+		{"github_id asc", "github_id"},
+	}
+
+	for _, tt := range tests {
+		d := &Deployment{ID: "deployment-1", GitHubID: 42}
+		d.PreInsert(nil)
+
+		cursor, err := d.Cursor(tt.order)
+		if err != nil {
+			t.Fatalf("Cursor(%q): %v", tt.order, err)
+		}
+
+		column, _, id, err := decodeCursor(cursor)
+		if err != nil {
+			t.Fatalf("decodeCursor(%q): %v", cursor, err)
+		}
+
+		if column != tt.column {
+			t.Errorf("order %q: column = %q, want %q", tt.order, column, tt.column)
+		}
+		if id != d.ID {
+			t.Errorf("order %q: id = %q, want %q", tt.order, id, d.ID)
+		}
+	}
+}
+
+// TestOrderByIncludesIDTiebreaker verifies that the generated ORDER BY
+// clause always ends with an id tiebreaker in the same direction as the
+// requested column, matching the (column, id) tuple the keyset cursor
+// compares against.
+func TestOrderByIncludesIDTiebreaker(t *testing.T) {
+	tests := []struct {
+		order string
+		want  string
+	}{
+		{"", "created_at desc, id desc"},
+		{"created_at asc", "created_at asc, id asc"},
+		{"github_id desc", "github_id desc, id desc"},
+	}
+
+	for _, tt := range tests {
+		got, err := (DeploymentsQuery{Order: tt.order}).orderBy()
+		if err != nil {
+			t.Fatalf("orderBy(%q): %v", tt.order, err)
+		}
+		if got != tt.want {
+			t.Errorf("orderBy(%q) = %q, want %q", tt.order, got, tt.want)
+		}
+	}
+}
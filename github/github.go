@@ -0,0 +1,216 @@
+// Package github provides a minimal client for the parts of the GitHub API
+// that tugboat needs: creating deployments, posting deployment statuses and
+// reading combined commit statuses.
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultBaseURL is the base url for the GitHub API.
+const DefaultBaseURL = "https://api.github.com"
+
+// deploymentsAccept is the Accept header required to opt in to the
+// `environment`/`transient_environment`/`production_environment` fields on
+// the Deployments API, which are still in preview.
+const deploymentsAccept = "application/vnd.github.ant-man-preview+json"
+
+// Client is a small GitHub API client scoped to the deployments, deployment
+// statuses and combined commit status endpoints.
+type Client struct {
+	// BaseURL is the base url of the GitHub API. Defaults to
+	// DefaultBaseURL.
+	BaseURL string
+
+	// Token is the access token used to authenticate requests.
+	Token string
+
+	// HTTPClient is used to perform requests. Defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// NewClient returns a new Client that authenticates with the given token.
+func NewClient(token string) *Client {
+	return &Client{
+		BaseURL: DefaultBaseURL,
+		Token:   token,
+	}
+}
+
+// DeploymentRequest represents the body of a create deployment request.
+//
+// See https://developer.github.com/v3/repos/deployments/#create-a-deployment
+type DeploymentRequest struct {
+	Ref                   string          `json:"ref"`
+	Task                  string          `json:"task,omitempty"`
+	AutoMerge             *bool           `json:"auto_merge,omitempty"`
+	RequiredContexts      []string        `json:"required_contexts,omitempty"`
+	Payload               json.RawMessage `json:"payload,omitempty"`
+	Environment           string          `json:"environment,omitempty"`
+	Description           string          `json:"description,omitempty"`
+	TransientEnvironment  bool            `json:"transient_environment,omitempty"`
+	ProductionEnvironment bool            `json:"production_environment,omitempty"`
+}
+
+// Deployment represents a GitHub deployment.
+type Deployment struct {
+	ID                    int64           `json:"id"`
+	Sha                   string          `json:"sha"`
+	Ref                   string          `json:"ref"`
+	Task                  string          `json:"task"`
+	Payload               json.RawMessage `json:"payload"`
+	Environment           string          `json:"environment"`
+	Description           string          `json:"description"`
+	Creator               User            `json:"creator"`
+	TransientEnvironment  bool            `json:"transient_environment"`
+	ProductionEnvironment bool            `json:"production_environment"`
+	StatusesURL           string          `json:"statuses_url"`
+	URL                   string          `json:"url"`
+	CreatedAt             time.Time       `json:"created_at"`
+	UpdatedAt             time.Time       `json:"updated_at"`
+}
+
+// User represents the creator of a deployment.
+type User struct {
+	Login string `json:"login"`
+	ID    int64  `json:"id"`
+}
+
+// DeploymentStatusRequest represents the body of a create deployment status
+// request.
+//
+// See https://developer.github.com/v3/repos/deployments/#create-a-deployment-status
+type DeploymentStatusRequest struct {
+	State          string `json:"state"`
+	TargetURL      string `json:"target_url,omitempty"`
+	Description    string `json:"description,omitempty"`
+	Environment    string `json:"environment,omitempty"`
+	EnvironmentURL string `json:"environment_url,omitempty"`
+	AutoInactive   *bool  `json:"auto_inactive,omitempty"`
+}
+
+// DeploymentStatus represents a GitHub deployment status.
+type DeploymentStatus struct {
+	ID          int64     `json:"id"`
+	State       string    `json:"state"`
+	TargetURL   string    `json:"target_url"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CreateDeployment creates a new deployment on the given repo.
+func (c *Client) CreateDeployment(ctx context.Context, owner, repo string, req *DeploymentRequest) (*Deployment, error) {
+	u := fmt.Sprintf("%s/repos/%s/%s/deployments", c.baseURL(), owner, repo)
+
+	var d Deployment
+	if err := c.do(ctx, "POST", u, req, &d); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// CreateDeploymentStatus creates a new status for the given deployment.
+func (c *Client) CreateDeploymentStatus(ctx context.Context, owner, repo string, deploymentID int64, req *DeploymentStatusRequest) (*DeploymentStatus, error) {
+	u := fmt.Sprintf("%s/repos/%s/%s/deployments/%d/statuses", c.baseURL(), owner, repo, deploymentID)
+
+	var s DeploymentStatus
+	if err := c.do(ctx, "POST", u, req, &s); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// Status represents a single commit status.
+type Status struct {
+	State   string `json:"state"`
+	Context string `json:"context"`
+}
+
+// CombinedStatus represents the combined status of a commit, as returned by
+// the combined status API.
+type CombinedStatus struct {
+	State    string   `json:"state"`
+	Statuses []Status `json:"statuses"`
+}
+
+// GetCombinedStatus returns the combined commit status for ref (a sha,
+// branch, or tag).
+func (c *Client) GetCombinedStatus(ctx context.Context, owner, repo, ref string) (*CombinedStatus, error) {
+	u := fmt.Sprintf("%s/repos/%s/%s/commits/%s/status", c.baseURL(), owner, repo, ref)
+
+	var s CombinedStatus
+	if err := c.do(ctx, "GET", u, nil, &s); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (c *Client) baseURL() string {
+	if c.BaseURL == "" {
+		return DefaultBaseURL
+	}
+	return c.BaseURL
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+func (c *Client) do(ctx context.Context, method, url string, body, v interface{}) error {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, url, &buf)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", deploymentsAccept)
+	if c.Token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("token %s", c.Token))
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &Error{StatusCode: resp.StatusCode}
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Error is returned when the GitHub API responds with a non-2xx status
+// code.
+type Error struct {
+	StatusCode int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("github: unexpected status code: %d", e.StatusCode)
+}
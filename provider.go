@@ -0,0 +1,146 @@
+package tugboat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Provider performs the actual work of deploying a Deployment to a platform
+// (e.g. Heroku, Empire, Kubernetes).
+type Provider interface {
+	// Name returns the unique name that this provider is registered
+	// under (e.g. "heroku", "empire", "kubernetes").
+	Name() string
+
+	// Deploy performs the deployment described by d, streaming any
+	// output to w.
+	Deploy(ctx context.Context, d *Deployment, w io.Writer) error
+
+	// Rollback rolls back d to whatever was previously deployed.
+	Rollback(ctx context.Context, d *Deployment) error
+
+	// Supports returns true if this provider is able to handle a
+	// deployment with the given opts. Used to pick a provider when
+	// DeployOpts.Provider isn't set explicitly.
+	Supports(opts DeployOpts) bool
+}
+
+var (
+	providersMu sync.Mutex
+	providers   = make(map[string]Provider)
+)
+
+// RegisterProvider registers p so that it can be looked up by name, and
+// considered when a DeployOpts doesn't specify a provider explicitly.
+func RegisterProvider(p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	providers[p.Name()] = p
+}
+
+// LookupProvider returns the Provider registered under name.
+func LookupProvider(name string) (Provider, bool) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	p, ok := providers[name]
+	return p, ok
+}
+
+// SelectProvider returns the Provider that should be used for opts. If
+// opts.Provider is set, the provider registered under that name is used.
+// Otherwise, the registered providers are consulted, in name order, and the
+// first one whose Supports method returns true is used.
+func SelectProvider(opts DeployOpts) (Provider, bool) {
+	if opts.Provider != "" {
+		return LookupProvider(opts.Provider)
+	}
+
+	providersMu.Lock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	providersMu.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		p, ok := LookupProvider(name)
+		if ok && p.Supports(opts) {
+			return p, true
+		}
+	}
+
+	return nil, false
+}
+
+// ErrProviderNotFound is returned when a Deployment references a provider
+// that isn't registered.
+var ErrProviderNotFound = fmt.Errorf("tugboat: provider not found")
+
+// ProviderFailedError is returned by a Provider's Deploy method to indicate
+// that the deployment itself was rejected or failed on the platform (e.g. a
+// release was rejected, a rollout didn't complete), as opposed to an
+// infrastructure error talking to the platform. providerDeployer uses this
+// to mark the Deployment as StatusFailed instead of StatusErrored.
+type ProviderFailedError struct {
+	Err error
+}
+
+func (e *ProviderFailedError) Error() string {
+	return e.Err.Error()
+}
+
+// providerDeployer is a deployFunc that performs a Deployment by delegating
+// to the Provider named by Deployment.Provider, and updates the Deployment's
+// status with the result.
+type providerDeployer struct {
+	service deploymentsService
+	sink    LogSink
+}
+
+// newProviderDeployer returns a deployFunc that performs deployments using
+// the registered Provider, persisting status transitions through service
+// and teeing the provider's output to sink.
+func newProviderDeployer(service deploymentsService, sink LogSink) deployFunc {
+	d := &providerDeployer{service: service, sink: sink}
+	return d.Deploy
+}
+
+// Deploy looks up the Provider for d and runs it, marking d as Succeeded,
+// Failed, or Errored depending on the outcome. The provider's stdout/stderr
+// is teed to p.sink so it can be tailed while the deployment is running.
+func (p *providerDeployer) Deploy(d *Deployment) error {
+	provider, ok := LookupProvider(d.Provider)
+	if !ok {
+		d.Errored(ErrProviderNotFound)
+		p.service.DeploymentsUpdate(d)
+		return ErrProviderNotFound
+	}
+
+	w, err := p.sink.Writer(d.ID)
+	if err != nil {
+		d.Errored(err)
+		p.service.DeploymentsUpdate(d)
+		return err
+	}
+	defer w.Close()
+
+	if err := provider.Deploy(context.Background(), d, w); err != nil {
+		if failed, ok := err.(*ProviderFailedError); ok {
+			d.Error = failed.Error()
+			d.Failed()
+		} else {
+			d.Errored(err)
+		}
+		p.service.DeploymentsUpdate(d)
+		return err
+	}
+
+	d.Succeeded()
+	return p.service.DeploymentsUpdate(d)
+}
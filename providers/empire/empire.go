@@ -0,0 +1,61 @@
+// Package empire implements a tugboat.Provider that deploys by telling
+// Empire (https://github.com/remind101/empire) to deploy a Docker image tag.
+package empire
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	tugboat "github.com/CodeLingoBot/tugboat"
+)
+
+// deployer is the subset of the Empire API that's needed to trigger a
+// deploy.
+type deployer interface {
+	DeployImage(ctx context.Context, app, image string, w io.Writer) error
+}
+
+// Provider is a tugboat.Provider that deploys to Empire by deploying a
+// Docker image tag matching the Deployment's sha.
+type Provider struct {
+	// Image is the Docker repository to deploy from, e.g.
+	// "remind101/acme-inc". The Deployment's Sha is used as the tag.
+	Image string
+
+	client deployer
+}
+
+// New returns a new empire Provider that uses client to perform deploys.
+func New(client deployer) *Provider {
+	return &Provider{client: client}
+}
+
+// Name implements the tugboat.Provider interface.
+func (p *Provider) Name() string {
+	return "empire"
+}
+
+// Supports implements the tugboat.Provider interface.
+func (p *Provider) Supports(opts tugboat.DeployOpts) bool {
+	return opts.Provider == p.Name()
+}
+
+// Deploy implements the tugboat.Provider interface by deploying the Docker
+// image tagged with d.Sha to the app named after d.Environment.
+func (p *Provider) Deploy(ctx context.Context, d *tugboat.Deployment, w io.Writer) error {
+	image := fmt.Sprintf("%s:%s", p.Image, d.Sha)
+
+	if err := p.client.DeployImage(ctx, d.Environment, image, w); err != nil {
+		return fmt.Errorf("empire: deploy of %s to %s failed: %v", image, d.Environment, err)
+	}
+
+	return nil
+}
+
+// Rollback implements the tugboat.Provider interface. Empire doesn't have a
+// rollback primitive, so this always returns an error and changes nothing;
+// operators redeploy a prior sha instead.
+func (p *Provider) Rollback(ctx context.Context, d *tugboat.Deployment) error {
+	return fmt.Errorf("empire: rollback is not supported, redeploy a prior sha instead")
+}
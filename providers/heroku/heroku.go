@@ -0,0 +1,128 @@
+// Package heroku implements a tugboat.Provider that deploys by pushing a git
+// sha to a Heroku app and polling the resulting release until it finishes.
+package heroku
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	tugboat "github.com/CodeLingoBot/tugboat"
+)
+
+// pollInterval is how often the release phase is polled for completion.
+const pollInterval = 2 * time.Second
+
+// releaser is the subset of the Heroku Platform API that's needed to poll a
+// release's status after a push.
+type releaser interface {
+	LatestRelease(ctx context.Context, app string) (status string, err error)
+}
+
+// Provider is a tugboat.Provider that deploys to Heroku by pushing a git sha
+// to the app's git remote and waiting for the release phase to complete.
+type Provider struct {
+	// App is the name of the Heroku app to deploy. Defaults to the
+	// Deployment's Environment if empty.
+	App string
+
+	// Remote is the git remote url to push to. Defaults to
+	// `git@heroku.com:<app>.git`.
+	Remote string
+
+	releases releaser
+}
+
+// New returns a new heroku Provider that uses releases to poll the release
+// phase after a push. If releases is nil, Deploy returns once the push
+// succeeds, without polling.
+func New(releases releaser) *Provider {
+	return &Provider{releases: releases}
+}
+
+// Name implements the tugboat.Provider interface.
+func (p *Provider) Name() string {
+	return "heroku"
+}
+
+// Supports implements the tugboat.Provider interface. Heroku deploys are
+// only used when explicitly requested.
+func (p *Provider) Supports(opts tugboat.DeployOpts) bool {
+	return opts.Provider == p.Name()
+}
+
+// Deploy implements the tugboat.Provider interface by pushing d.Sha to the
+// app's git remote, then polling the release phase until it finishes.
+func (p *Provider) Deploy(ctx context.Context, d *tugboat.Deployment, w io.Writer) error {
+	app := p.app(d)
+	remote := p.remote(app)
+
+	cmd := exec.CommandContext(ctx, "git", "push", remote, fmt.Sprintf("%s:refs/heads/master", d.Sha))
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("heroku: push to %s failed: %v", app, err)
+	}
+
+	if p.releases == nil {
+		// No Platform API client configured; the push having
+		// succeeded is all we can confirm.
+		return nil
+	}
+
+	return p.waitForRelease(ctx, app, w)
+}
+
+// Rollback implements the tugboat.Provider interface.
+func (p *Provider) Rollback(ctx context.Context, d *tugboat.Deployment) error {
+	app := p.app(d)
+	cmd := exec.CommandContext(ctx, "heroku", "rollback", "--app", app)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("heroku: rollback of %s failed: %v: %s", app, err, out.String())
+	}
+	return nil
+}
+
+func (p *Provider) waitForRelease(ctx context.Context, app string, w io.Writer) error {
+	for {
+		status, err := p.releases.LatestRelease(ctx, app)
+		if err != nil {
+			return err
+		}
+
+		switch status {
+		case "succeeded":
+			return nil
+		case "failed":
+			return &tugboat.ProviderFailedError{Err: fmt.Errorf("heroku: release for %s failed", app)}
+		}
+
+		fmt.Fprintf(w, "waiting for release to %s (status=%s)\n", app, status)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func (p *Provider) app(d *tugboat.Deployment) string {
+	if p.App != "" {
+		return p.App
+	}
+	return d.Environment
+}
+
+func (p *Provider) remote(app string) string {
+	if p.Remote != "" {
+		return p.Remote
+	}
+	return fmt.Sprintf("git@heroku.com:%s.git", app)
+}
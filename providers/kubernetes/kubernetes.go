@@ -0,0 +1,112 @@
+// Package kubernetes implements a tugboat.Provider that deploys by setting
+// the image on a Deployment's containers via `kubectl set image`.
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+
+	tugboat "github.com/CodeLingoBot/tugboat"
+)
+
+// Provider is a tugboat.Provider that deploys to a Kubernetes cluster by
+// updating the image of a Deployment's containers.
+type Provider struct {
+	// Image is the Docker repository to deploy from. The tugboat
+	// Deployment's Sha is used as the tag.
+	Image string
+
+	// Container is the name of the container within the Kubernetes
+	// Deployment whose image should be updated. Defaults to the
+	// Kubernetes Deployment's name.
+	Container string
+
+	// Namespace is the Kubernetes namespace to operate in. Defaults to
+	// "default".
+	Namespace string
+
+	// Kubectl is the path to the kubectl binary. Defaults to "kubectl".
+	Kubectl string
+}
+
+// New returns a new kubernetes Provider.
+func New() *Provider {
+	return &Provider{}
+}
+
+// Name implements the tugboat.Provider interface.
+func (p *Provider) Name() string {
+	return "kubernetes"
+}
+
+// Supports implements the tugboat.Provider interface.
+func (p *Provider) Supports(opts tugboat.DeployOpts) bool {
+	return opts.Provider == p.Name()
+}
+
+// Deploy implements the tugboat.Provider interface by running `kubectl set
+// image` against the Kubernetes Deployment named after d.Environment.
+func (p *Provider) Deploy(ctx context.Context, d *tugboat.Deployment, w io.Writer) error {
+	deployment := d.Environment
+	container := p.Container
+	if container == "" {
+		container = deployment
+	}
+
+	image := fmt.Sprintf("%s:%s", p.Image, d.Sha)
+
+	cmd := p.command(ctx,
+		"set", "image",
+		fmt.Sprintf("deployment/%s", deployment),
+		fmt.Sprintf("%s=%s", container, image),
+		"--namespace", p.namespace(),
+	)
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("kubernetes: set image on %s failed: %v", deployment, err)
+	}
+
+	cmd = p.command(ctx, "rollout", "status", fmt.Sprintf("deployment/%s", deployment), "--namespace", p.namespace())
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Run(); err != nil {
+		return &tugboat.ProviderFailedError{Err: fmt.Errorf("kubernetes: rollout of %s did not complete: %v", deployment, err)}
+	}
+
+	return nil
+}
+
+// Rollback implements the tugboat.Provider interface by undoing the most
+// recent rollout of the Kubernetes Deployment.
+func (p *Provider) Rollback(ctx context.Context, d *tugboat.Deployment) error {
+	cmd := p.command(ctx, "rollout", "undo", fmt.Sprintf("deployment/%s", d.Environment), "--namespace", p.namespace())
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("kubernetes: rollback of %s failed: %v: %s", d.Environment, err, out.String())
+	}
+	return nil
+}
+
+func (p *Provider) command(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, p.kubectl(), args...)
+}
+
+func (p *Provider) kubectl() string {
+	if p.Kubectl != "" {
+		return p.Kubectl
+	}
+	return "kubectl"
+}
+
+func (p *Provider) namespace() string {
+	if p.Namespace != "" {
+		return p.Namespace
+	}
+	return "default"
+}